@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// backendView is the JSON shape returned by GET /api/backends.
+type backendView struct {
+	Address     string    `json:"address"`
+	Weight      int       `json:"weight"`
+	Healthy     bool      `json:"healthy"`
+	InFlight    int64     `json:"inFlight"`
+	TotalServed int64     `json:"totalServed"`
+	LastCheck   time.Time `json:"lastCheck"`
+}
+
+// backendMutation is the JSON body for POST /api/backends.
+type backendMutation struct {
+	Action  string `json:"action"` // "add" or "remove"
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+}
+
+// startRequest is the JSON body for POST /api/start. It mirrors the fields
+// of StartOptions that are meaningful over the wire; TCP tuning uses the
+// GUI defaults and no accept-error callback is set.
+type startRequest struct {
+	ListenPort          int      `json:"listenPort"`
+	Backends            []string `json:"backends"`
+	Balancer            string   `json:"balancer"`
+	RetryCount          int      `json:"retryCount"`
+	DrainTimeoutSeconds int      `json:"drainTimeoutSeconds"`
+}
+
+func (r startRequest) toStartOptions() StartOptions {
+	return StartOptions{
+		ListenPort:   r.ListenPort,
+		BackendSpecs: r.Backends,
+		Balancer:     r.Balancer,
+		HealthCheck:  DefaultHealthCheckConfig(),
+		RetryCount:   r.RetryCount,
+		DrainTimeout: time.Duration(r.DrainTimeoutSeconds) * time.Second,
+		TCPTuning:    DefaultTCPTuningConfig(),
+	}
+}
+
+// AdminServer exposes LBManager state over HTTP so it can be inspected and
+// driven without the Fyne window, e.g. in a headless deployment.
+type AdminServer struct {
+	manager *LBManager
+	httpSrv *http.Server
+}
+
+// NewAdminServer returns an AdminServer backed by manager.
+func NewAdminServer(manager *LBManager) *AdminServer {
+	return &AdminServer{manager: manager}
+}
+
+// Start begins serving the admin API on port in a background goroutine.
+func (a *AdminServer) Start(port int) error {
+	if a.httpSrv != nil {
+		return fmt.Errorf("admin server already running")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/connections", a.handleConnections)
+	mux.HandleFunc("/api/backends", a.handleBackends)
+	mux.HandleFunc("/api/stop", a.handleStop)
+	mux.HandleFunc("/api/start", a.handleStart)
+	mux.HandleFunc("/metrics", a.handleMetrics)
+
+	a.httpSrv = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	go func() {
+		if err := a.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin server error: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// Stop shuts down the admin API server.
+func (a *AdminServer) Stop() error {
+	if a.httpSrv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := a.httpSrv.Shutdown(ctx)
+	a.httpSrv = nil
+	return err
+}
+
+func (a *AdminServer) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.manager.ListConnections())
+}
+
+func (a *AdminServer) handleBackends(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backends := a.manager.ListBackends()
+		views := make([]backendView, 0, len(backends))
+		for _, b := range backends {
+			views = append(views, backendView{
+				Address:     b.Address,
+				Weight:      b.Weight,
+				Healthy:     b.Healthy(),
+				InFlight:    b.InFlight(),
+				TotalServed: b.TotalServed(),
+				LastCheck:   b.LastCheck(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(views)
+
+	case http.MethodPost:
+		var mutation backendMutation
+		if err := json.NewDecoder(r.Body).Decode(&mutation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		switch mutation.Action {
+		case "add":
+			spec := mutation.Address
+			if mutation.Weight > 0 {
+				spec = fmt.Sprintf("%s,%d", mutation.Address, mutation.Weight)
+			}
+			err = a.manager.AddBackend(spec)
+		case "remove":
+			err = a.manager.RemoveBackend(mutation.Address)
+		default:
+			err = fmt.Errorf("unknown action %q", mutation.Action)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AdminServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.manager.StopListener(nil)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *AdminServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.manager.StartListener(req.toStartOptions()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *AdminServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = io.WriteString(w, a.manager.metrics.RenderPrometheus(int64(len(a.manager.ListConnections()))))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend represents a single upstream server and its live state.
+type Backend struct {
+	Address string // host:port
+	Weight  int    // used by the weighted round-robin strategy
+
+	mu          sync.RWMutex
+	healthy     bool
+	lastCheck   time.Time
+	consecOK    int
+	consecFail  int
+	inFlight    int64
+	totalServed int64
+}
+
+// NewBackend creates a Backend in the (optimistic) healthy state so it can
+// serve traffic before the first health check completes.
+func NewBackend(address string, weight int) *Backend {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &Backend{
+		Address: address,
+		Weight:  weight,
+		healthy: true,
+	}
+}
+
+// Healthy reports whether the backend is currently eligible for selection.
+func (b *Backend) Healthy() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.healthy
+}
+
+// LastCheck returns the time of the most recently completed health check.
+func (b *Backend) LastCheck() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastCheck
+}
+
+// InFlight returns the number of connections currently proxied to this backend.
+func (b *Backend) InFlight() int64 {
+	return atomic.LoadInt64(&b.inFlight)
+}
+
+// TotalServed returns the lifetime count of connections handed to this backend.
+func (b *Backend) TotalServed() int64 {
+	return atomic.LoadInt64(&b.totalServed)
+}
+
+// acquire marks the start of a new connection to this backend.
+func (b *Backend) acquire() {
+	atomic.AddInt64(&b.inFlight, 1)
+	atomic.AddInt64(&b.totalServed, 1)
+}
+
+// release marks the end of a connection to this backend.
+func (b *Backend) release() {
+	atomic.AddInt64(&b.inFlight, -1)
+}
+
+// recordCheck folds the result of one health probe into the backend's
+// consecutive success/failure counters and flips healthy once the
+// configured threshold is reached.
+func (b *Backend) recordCheck(ok bool, healthyThreshold, unhealthyThreshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastCheck = time.Now()
+	if ok {
+		b.consecOK++
+		b.consecFail = 0
+		if !b.healthy && b.consecOK >= healthyThreshold {
+			b.healthy = true
+		}
+	} else {
+		b.consecFail++
+		b.consecOK = 0
+		if b.healthy && b.consecFail >= unhealthyThreshold {
+			b.healthy = false
+		}
+	}
+}
+
+// Balancer selects a backend from a pool of already-healthy candidates.
+type Balancer interface {
+	// Name identifies the strategy for display in the UI.
+	Name() string
+	// Pick returns the next backend to use for clientAddr, the remote
+	// address of the connecting client (used by strategies that need
+	// client affinity).
+	Pick(backends []*Backend, clientAddr string) (*Backend, error)
+}
+
+var errNoHealthyBackends = fmt.Errorf("no healthy backends available")
+
+// roundRobinBalancer cycles through backends in order, ignoring weight.
+type roundRobinBalancer struct {
+	counter uint64
+}
+
+func (b *roundRobinBalancer) Name() string { return "round-robin" }
+
+func (b *roundRobinBalancer) Pick(backends []*Backend, clientAddr string) (*Backend, error) {
+	if len(backends) == 0 {
+		return nil, errNoHealthyBackends
+	}
+	n := atomic.AddUint64(&b.counter, 1)
+	return backends[int(n-1)%len(backends)], nil
+}
+
+// weightedRoundRobinBalancer cycles through backends, each appearing in the
+// rotation Weight times.
+type weightedRoundRobinBalancer struct {
+	counter uint64
+}
+
+func (b *weightedRoundRobinBalancer) Name() string { return "weighted-round-robin" }
+
+func (b *weightedRoundRobinBalancer) Pick(backends []*Backend, clientAddr string) (*Backend, error) {
+	if len(backends) == 0 {
+		return nil, errNoHealthyBackends
+	}
+	total := 0
+	for _, be := range backends {
+		total += be.Weight
+	}
+	if total == 0 {
+		return nil, errNoHealthyBackends
+	}
+	n := atomic.AddUint64(&b.counter, 1)
+	target := int(n-1) % total
+	for _, be := range backends {
+		if target < be.Weight {
+			return be, nil
+		}
+		target -= be.Weight
+	}
+	return backends[len(backends)-1], nil
+}
+
+// leastConnectionsBalancer picks the backend with the fewest in-flight
+// connections, breaking ties by address for determinism.
+type leastConnectionsBalancer struct{}
+
+func (b *leastConnectionsBalancer) Name() string { return "least-connections" }
+
+func (b *leastConnectionsBalancer) Pick(backends []*Backend, clientAddr string) (*Backend, error) {
+	if len(backends) == 0 {
+		return nil, errNoHealthyBackends
+	}
+	best := backends[0]
+	bestInFlight := best.InFlight()
+	for _, be := range backends[1:] {
+		n := be.InFlight()
+		if n < bestInFlight || (n == bestInFlight && be.Address < best.Address) {
+			best, bestInFlight = be, n
+		}
+	}
+	return best, nil
+}
+
+// sourceIPHashBalancer hashes the client's address so the same client keeps
+// landing on the same backend as long as the pool is stable.
+type sourceIPHashBalancer struct{}
+
+func (b *sourceIPHashBalancer) Name() string { return "source-ip-hash" }
+
+func (b *sourceIPHashBalancer) Pick(backends []*Backend, clientAddr string) (*Backend, error) {
+	if len(backends) == 0 {
+		return nil, errNoHealthyBackends
+	}
+	key := clientAddr
+	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
+		key = host
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	idx := int(h.Sum32()) % len(backends)
+	if idx < 0 {
+		idx += len(backends)
+	}
+	return backends[idx], nil
+}
+
+// balancerNames lists the strategies in the order they should appear in the UI.
+var balancerNames = []string{
+	"round-robin",
+	"weighted-round-robin",
+	"least-connections",
+	"source-ip-hash",
+}
+
+// newBalancer constructs the Balancer for the given strategy name, defaulting
+// to round-robin for an unrecognized name.
+func newBalancer(name string) Balancer {
+	switch name {
+	case "weighted-round-robin":
+		return &weightedRoundRobinBalancer{}
+	case "least-connections":
+		return &leastConnectionsBalancer{}
+	case "source-ip-hash":
+		return &sourceIPHashBalancer{}
+	default:
+		return &roundRobinBalancer{}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// HealthCheckConfig controls the active TCP health checker.
+type HealthCheckConfig struct {
+	Interval           time.Duration // time between probes
+	Timeout            time.Duration // per-probe dial timeout
+	HealthyThreshold   int           // consecutive successes to mark healthy
+	UnhealthyThreshold int           // consecutive failures to mark unhealthy
+}
+
+// DefaultHealthCheckConfig returns sane defaults for interactive use.
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Interval:           5 * time.Second,
+		Timeout:            2 * time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+}
+
+// runHealthChecker probes backend on cfg.Interval by dialing its address,
+// updating its healthy flag, until stop is closed. It is meant to be run in
+// its own goroutine, one per backend.
+func runHealthChecker(backend *Backend, cfg HealthCheckConfig, stop <-chan struct{}) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ok := probeTCP(backend.Address, cfg.Timeout)
+			backend.recordCheck(ok, cfg.HealthyThreshold, cfg.UnhealthyThreshold)
+		}
+	}
+}
+
+// probeTCP reports whether a TCP dial to address succeeds within timeout.
+func probeTCP(address string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
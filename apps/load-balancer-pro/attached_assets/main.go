@@ -1,311 +1,361 @@
 package main
 
 import (
-    "context"
-    "fmt"
-    "io"
-    "log"
-    "math/rand"
-    "net"
-    "strconv"
-    "strings"
-    "sync"
-    "time"
-
-    "fyne.io/fyne/v2"
-    "fyne.io/fyne/v2/app"
-    "fyne.io/fyne/v2/container"
-    "fyne.io/fyne/v2/widget"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
 )
 
-// ConnectionInfo holds metadata for a single active connection
-type ConnectionInfo struct {
-    ID         string
-    Source     string
-    Destination string
-    StartTime  time.Time
-}
-
-// LBManager manages the load balancer state
-type LBManager struct {
-    mu           sync.Mutex             // protects activeConns
-    activeConns  []ConnectionInfo
-    backends     []string
-    backendIndex int
-
-    cancelFunc   context.CancelFunc     // used to stop the listener goroutine
-    running      bool
-}
-
-// AddConnection adds a connection record to the active list
-func (m *LBManager) AddConnection(ci ConnectionInfo) {
-    m.mu.Lock()
-    defer m.mu.Unlock()
-    m.activeConns = append(m.activeConns, ci)
-}
-
-// RemoveConnectionByID removes a connection by matching ID
-func (m *LBManager) RemoveConnectionByID(id string) {
-    m.mu.Lock()
-    defer m.mu.Unlock()
-    for i, c := range m.activeConns {
-        if c.ID == id {
-            // remove it
-            m.activeConns = append(m.activeConns[:i], m.activeConns[i+1:]...)
-            break
-        }
-    }
-}
-
-// ListConnections returns a snapshot of active connections
-func (m *LBManager) ListConnections() []ConnectionInfo {
-    m.mu.Lock()
-    defer m.mu.Unlock()
-    // return a copy
-    copied := make([]ConnectionInfo, len(m.activeConns))
-    copy(copied, m.activeConns)
-    return copied
-}
-
-// pickBackend does a round-robin pick of a backend
-func (m *LBManager) pickBackend() (string, string, error) {
-    if len(m.backends) == 0 {
-        return "", "", fmt.Errorf("no backends available")
-    }
-    backend := m.backends[m.backendIndex%len(m.backends)]
-    m.backendIndex++
-    parts := strings.Split(backend, ":")
-    if len(parts) != 2 {
-        return "", "", fmt.Errorf("invalid backend format: %s", backend)
-    }
-    return parts[0], parts[1], nil
-}
-
-// StartListener starts the load balancer in a background goroutine
-func (m *LBManager) StartListener(listenPort int, backends []string) error {
-    if m.running {
-        return fmt.Errorf("already running")
-    }
-
-    m.backends = backends
-    m.backendIndex = 0
-    m.running = true
-
-    ctx, cancel := context.WithCancel(context.Background())
-    m.cancelFunc = cancel
-
-    go func() {
-        defer func() {
-            m.running = false
-        }()
-								listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: listenPort})
-								if err != nil {
-								    log.Printf("Error listening on port %d: %v\n", listenPort, err)
-								    return
-								}
-								defer listener.Close()
-
-        log.Printf("Load balancer listening on port %d\n", listenPort)
-
-        for {
-            select {
-            case <-ctx.Done():
-                // stop signaled
-                log.Println("Stopping load balancer...")
-                return
-            default:
-            }
-
-            // Accept new connections
-            listener.SetDeadline(time.Now().Add(200 * time.Millisecond)) // short timeout so we can check ctx
-            conn, err := listener.Accept()
-            if err != nil {
-                // check if it's a timeout or a real error
-                netErr, ok := err.(net.Error)
-                if ok && netErr.Timeout() {
-                    // normal, keep looping
-                    continue
-                }
-                log.Printf("Accept error: %v\n", err)
-                continue
-            }
-
-            // We got a new client - handle in a goroutine
-            go m.handleClient(ctx, conn)
-        }
-    }()
-    return nil
-}
-
-// StopListener signals the load balancer to stop
-func (m *LBManager) StopListener() {
-    if m.cancelFunc != nil {
-        m.cancelFunc() // signal the goroutine to stop
-    }
-    m.running = false
-    log.Println("StopListener called")
-}
-
-// handleClient proxies traffic to the next backend
-func (m *LBManager) handleClient(ctx context.Context, clientConn net.Conn) {
-    defer clientConn.Close()
-
-    host, port, err := m.pickBackend()
-    if err != nil {
-        log.Printf("Error picking backend: %v\n", err)
-        return
-    }
-
-    backendConn, err := net.Dial("tcp", net.JoinHostPort(host, port))
-    if err != nil {
-        log.Printf("Error connecting to backend %s:%s => %v\n", host, port, err)
-        return
-    }
-    defer backendConn.Close()
-
-    // create connection record
-    connID := fmt.Sprintf("%d", rand.Uint64())
-    srcAddr := clientConn.RemoteAddr().String()
-    dstAddr := net.JoinHostPort(host, port)
-
-    ci := ConnectionInfo{
-        ID:          connID,
-        Source:      srcAddr,
-        Destination: dstAddr,
-        StartTime:   time.Now(),
-    }
-    m.AddConnection(ci)
-    defer m.RemoveConnectionByID(connID)
-
-    // copy data both ways
-    // We'll do io.Copy in goroutines:
-    done := make(chan struct{}, 2)
-
-    go func() {
-        defer func() { done <- struct{}{} }()
-        _, _ = io.Copy(backendConn, clientConn)
-    }()
-    go func() {
-        defer func() { done <- struct{}{} }()
-        _, _ = io.Copy(clientConn, backendConn)
-    }()
-
-    // Wait for both directions to finish or context done
-    select {
-    case <-done:
-    case <-done:
-        // we read from the channel twice so this ensures both goroutines have ended
-    case <-ctx.Done():
-        // context canceled
-    }
-}
-
 // ------------------------------------------------------------------------
 // GUI / Fyne
 // ------------------------------------------------------------------------
 
 func main() {
-    // create the Fyne application
-    myApp := app.New()
-    w := myApp.NewWindow("Go + Fyne Load Balancer")
-
-    // manager that holds state
-    manager := &LBManager{
-        activeConns: make([]ConnectionInfo, 0),
-    }
-    rand.Seed(time.Now().UnixNano())
-
-    // UI controls
-    portEntry := widget.NewEntry()
-    portEntry.SetText("8080")
-
-    backendsEntry := widget.NewMultiLineEntry()
-    backendsEntry.SetText("127.0.0.1:8081\n127.0.0.1:8082")
-
-    statusLabel := widget.NewLabel("Status: Stopped")
-
-    // We'll use a simple list to display active connections
-    // Alternatively, you can use widget.NewTable, container.NewGrid, etc.
-    list := widget.NewList(
-        func() int {
-            return len(manager.ListConnections())
-        },
-        func() fyne.CanvasObject {
-            return widget.NewLabel("") // a template
-        },
-        func(i widget.ListItemID, o fyne.CanvasObject) {
-            // update
-            conns := manager.ListConnections()
-            if i >= 0 && i < len(conns) {
-                c := conns[i]
-                o.(*widget.Label).SetText(
-                    fmt.Sprintf("ID:%s | %s -> %s | %s",
-                        c.ID, c.Source, c.Destination, c.StartTime.Format("15:04:05")),
-                )
-            }
-        },
-    )
-
-    startBtn := widget.NewButton("Start", func() {
-        portStr := portEntry.Text
-        p, err := strconv.Atoi(portStr)
-        if err != nil {
-            statusLabel.SetText("Status: Invalid port")
-            return
-        }
-        lines := strings.Split(backendsEntry.Text, "\n")
-        var backends []string
-        for _, ln := range lines {
-            ln = strings.TrimSpace(ln)
-            if ln != "" {
-                backends = append(backends, ln)
-            }
-        }
-        if len(backends) == 0 {
-            statusLabel.SetText("Status: No backends provided")
-            return
-        }
-
-        err = manager.StartListener(p, backends)
-        if err != nil {
-            statusLabel.SetText(fmt.Sprintf("Status: Error: %v", err))
-            return
-        }
-        statusLabel.SetText(fmt.Sprintf("Status: Running on port %d", p))
-    })
-
-    stopBtn := widget.NewButton("Stop", func() {
-        manager.StopListener()
-        statusLabel.SetText("Status: Stopped")
-    })
-
-    // layout
-    form := container.NewVBox(
-        widget.NewLabel("Listen Port:"),
-        portEntry,
-        widget.NewLabel("Backend Servers (host:port, one per line):"),
-        backendsEntry,
-        container.NewHBox(startBtn, stopBtn),
-        statusLabel,
-        widget.NewLabel("Active Connections:"),
-        list,
-    )
-
-    w.SetContent(form)
-    w.Resize(fyne.NewSize(500, 500))
-
-    // We'll set up a ticker to refresh the list
-    go func() {
-        ticker := time.NewTicker(1 * time.Second)
-        for range ticker.C {
-												if w.Content() == nil {
-												    ticker.Stop()
-												    return
-												}
-            // Refresh the list in the main UI thread
-												list.Refresh()
-        }
-    }()
-
-    w.ShowAndRun()
-}
\ No newline at end of file
+	// create the Fyne application
+	myApp := app.New()
+	w := myApp.NewWindow("Go + Fyne Load Balancer")
+
+	// manager that holds state
+	manager := NewLBManager()
+	admin := NewAdminServer(manager)
+	rand.Seed(time.Now().UnixNano())
+
+	// UI controls
+	portEntry := widget.NewEntry()
+	portEntry.SetText("8080")
+
+	backendsEntry := widget.NewMultiLineEntry()
+	backendsEntry.SetText("127.0.0.1:8081\n127.0.0.1:8082")
+
+	algoSelect := widget.NewSelect(balancerNames, func(string) {})
+	algoSelect.SetSelected(balancerNames[0])
+
+	retryEntry := widget.NewEntry()
+	retryEntry.SetText("2")
+
+	drainEntry := widget.NewEntry()
+	drainEntry.SetText("10")
+
+	// Advanced TCP tuning, tucked away in a collapsed accordion section
+	// since the defaults are fine for most users.
+	keepAliveCheck := widget.NewCheck("Keep-alive", func(bool) {})
+	keepAliveCheck.SetChecked(true)
+
+	keepAlivePeriodEntry := widget.NewEntry()
+	keepAlivePeriodEntry.SetText("30")
+
+	readBufEntry := widget.NewEntry()
+	readBufEntry.SetText("0")
+
+	writeBufEntry := widget.NewEntry()
+	writeBufEntry.SetText("0")
+
+	noDelayCheck := widget.NewCheck("TCP_NODELAY", func(bool) {})
+	noDelayCheck.SetChecked(true)
+
+	advanced := widget.NewAccordion(
+		widget.NewAccordionItem("Advanced", container.NewVBox(
+			keepAliveCheck,
+			widget.NewLabel("Keep-alive period (seconds):"),
+			keepAlivePeriodEntry,
+			widget.NewLabel("Read buffer size (bytes, 0 = OS default):"),
+			readBufEntry,
+			widget.NewLabel("Write buffer size (bytes, 0 = OS default):"),
+			writeBufEntry,
+			noDelayCheck,
+		)),
+	)
+
+	adminPortEntry := widget.NewEntry()
+	adminPortEntry.SetText("9090")
+
+	adminStatusLabel := widget.NewLabel("Admin API: Stopped")
+
+	// Telemetry sinks, tucked away in their own accordion section.
+	consoleSinkSelect := widget.NewSelect([]string{"none", "stdout", "stderr"}, func(string) {})
+	consoleSinkSelect.SetSelected("none")
+
+	fsSinkCheck := widget.NewCheck("Enable filesystem sink", func(bool) {})
+	fsPathEntry := widget.NewEntry()
+	fsPathEntry.SetText("connections.log")
+	fsMaxSizeEntry := widget.NewEntry()
+	fsMaxSizeEntry.SetText("100")
+	fsMaxBackupsEntry := widget.NewEntry()
+	fsMaxBackupsEntry.SetText("3")
+	fsMaxAgeEntry := widget.NewEntry()
+	fsMaxAgeEntry.SetText("28")
+
+	httpSinkCheck := widget.NewCheck("Enable HTTP sink", func(bool) {})
+	httpSinkURLEntry := widget.NewEntry()
+	httpSinkURLEntry.SetText("http://localhost:9091/events")
+
+	sinks := widget.NewAccordion(
+		widget.NewAccordionItem("Telemetry", container.NewVBox(
+			widget.NewLabel("Console sink:"),
+			consoleSinkSelect,
+			fsSinkCheck,
+			widget.NewLabel("Log file path:"),
+			fsPathEntry,
+			widget.NewLabel("Max size (MB) / backups / age (days):"),
+			container.NewHBox(fsMaxSizeEntry, fsMaxBackupsEntry, fsMaxAgeEntry),
+			httpSinkCheck,
+			widget.NewLabel("HTTP sink URL:"),
+			httpSinkURLEntry,
+		)),
+	)
+
+	statusLabel := widget.NewLabel("Status: Stopped")
+
+	drainProgress := func(remaining int) {
+		if remaining > 0 {
+			statusLabel.SetText(fmt.Sprintf("Draining: %d connections remaining", remaining))
+		}
+	}
+
+	// We'll use a simple list to display active connections
+	// Alternatively, you can use widget.NewTable, container.NewGrid, etc.
+	list := widget.NewList(
+		func() int {
+			return len(manager.ListConnections())
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("") // a template
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			// update
+			conns := manager.ListConnections()
+			if i >= 0 && i < len(conns) {
+				c := conns[i]
+				o.(*widget.Label).SetText(
+					fmt.Sprintf("ID:%s | %s -> %s | %s",
+						c.ID, c.Source, c.Destination, c.StartTime.Format("15:04:05")),
+				)
+			}
+		},
+	)
+
+	// A second list shows the live health/stats of each configured backend.
+	backendList := widget.NewList(
+		func() int {
+			return len(manager.ListBackends())
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("") // a template
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			backends := manager.ListBackends()
+			if i >= 0 && i < len(backends) {
+				b := backends[i]
+				state := "DOWN"
+				if b.Healthy() {
+					state = "UP"
+				}
+				o.(*widget.Label).SetText(
+					fmt.Sprintf("%s | %s | in-flight:%d | served:%d",
+						b.Address, state, b.InFlight(), b.TotalServed()),
+				)
+			}
+		},
+	)
+
+	startBtn := widget.NewButton("Start", func() {
+		portStr := portEntry.Text
+		p, err := strconv.Atoi(portStr)
+		if err != nil {
+			statusLabel.SetText("Status: Invalid port")
+			return
+		}
+		lines := strings.Split(backendsEntry.Text, "\n")
+		var backends []string
+		for _, ln := range lines {
+			ln = strings.TrimSpace(ln)
+			if ln != "" {
+				backends = append(backends, ln)
+			}
+		}
+		if len(backends) == 0 {
+			statusLabel.SetText("Status: No backends provided")
+			return
+		}
+		retries, err := strconv.Atoi(retryEntry.Text)
+		if err != nil || retries < 0 {
+			statusLabel.SetText("Status: Invalid retry count")
+			return
+		}
+		drainSecs, err := strconv.Atoi(drainEntry.Text)
+		if err != nil || drainSecs < 0 {
+			statusLabel.SetText("Status: Invalid drain timeout")
+			return
+		}
+		keepAlivePeriodSecs, err := strconv.Atoi(keepAlivePeriodEntry.Text)
+		if err != nil || keepAlivePeriodSecs < 0 {
+			statusLabel.SetText("Status: Invalid keep-alive period")
+			return
+		}
+		readBufSize, err := strconv.Atoi(readBufEntry.Text)
+		if err != nil || readBufSize < 0 {
+			statusLabel.SetText("Status: Invalid read buffer size")
+			return
+		}
+		writeBufSize, err := strconv.Atoi(writeBufEntry.Text)
+		if err != nil || writeBufSize < 0 {
+			statusLabel.SetText("Status: Invalid write buffer size")
+			return
+		}
+
+		var activeSinks []Sink
+		switch consoleSinkSelect.Selected {
+		case "stdout":
+			activeSinks = append(activeSinks, NewConsoleSink(os.Stdout))
+		case "stderr":
+			activeSinks = append(activeSinks, NewConsoleSink(os.Stderr))
+		}
+		if fsSinkCheck.Checked {
+			maxSizeMB, err := strconv.Atoi(fsMaxSizeEntry.Text)
+			if err != nil || maxSizeMB <= 0 {
+				statusLabel.SetText("Status: Invalid filesystem sink max size")
+				return
+			}
+			maxBackups, err := strconv.Atoi(fsMaxBackupsEntry.Text)
+			if err != nil || maxBackups < 0 {
+				statusLabel.SetText("Status: Invalid filesystem sink max backups")
+				return
+			}
+			maxAgeDays, err := strconv.Atoi(fsMaxAgeEntry.Text)
+			if err != nil || maxAgeDays < 0 {
+				statusLabel.SetText("Status: Invalid filesystem sink max age")
+				return
+			}
+			activeSinks = append(activeSinks, NewFilesystemSink(fsPathEntry.Text, maxSizeMB, maxBackups, maxAgeDays))
+		}
+		if httpSinkCheck.Checked {
+			activeSinks = append(activeSinks, NewHTTPSink(httpSinkURLEntry.Text))
+		}
+		switch len(activeSinks) {
+		case 0:
+			manager.SetSink(nil)
+		case 1:
+			manager.SetSink(activeSinks[0])
+		default:
+			manager.SetSink(NewMultiSink(activeSinks...))
+		}
+
+		err = manager.StartListener(StartOptions{
+			ListenPort:   p,
+			BackendSpecs: backends,
+			Balancer:     algoSelect.Selected,
+			HealthCheck:  DefaultHealthCheckConfig(),
+			RetryCount:   retries,
+			DrainTimeout: time.Duration(drainSecs) * time.Second,
+			TCPTuning: TCPTuningConfig{
+				KeepAlive:       keepAliveCheck.Checked,
+				KeepAlivePeriod: time.Duration(keepAlivePeriodSecs) * time.Second,
+				ReadBufferSize:  readBufSize,
+				WriteBufferSize: writeBufSize,
+				NoDelay:         noDelayCheck.Checked,
+			},
+			OnAcceptError: func(err error) {
+				statusLabel.SetText(fmt.Sprintf("Status: Accept error: %v", err))
+			},
+		})
+		if err != nil {
+			statusLabel.SetText(fmt.Sprintf("Status: Error: %v", err))
+			return
+		}
+		statusLabel.SetText(fmt.Sprintf("Status: Running on port %d (%s)", p, algoSelect.Selected))
+	})
+
+	stopBtn := widget.NewButton("Stop", func() {
+		statusLabel.SetText("Status: Draining...")
+		manager.StopListener(drainProgress)
+		statusLabel.SetText("Status: Stopped")
+	})
+
+	adminStartBtn := widget.NewButton("Start Admin API", func() {
+		port, err := strconv.Atoi(adminPortEntry.Text)
+		if err != nil {
+			adminStatusLabel.SetText("Admin API: Invalid port")
+			return
+		}
+		if err := admin.Start(port); err != nil {
+			adminStatusLabel.SetText(fmt.Sprintf("Admin API: Error: %v", err))
+			return
+		}
+		adminStatusLabel.SetText(fmt.Sprintf("Admin API: Running on port %d", port))
+	})
+
+	adminStopBtn := widget.NewButton("Stop Admin API", func() {
+		if err := admin.Stop(); err != nil {
+			adminStatusLabel.SetText(fmt.Sprintf("Admin API: Error: %v", err))
+			return
+		}
+		adminStatusLabel.SetText("Admin API: Stopped")
+	})
+
+	// layout
+	form := container.NewVBox(
+		widget.NewLabel("Listen Port:"),
+		portEntry,
+		widget.NewLabel("Backend Servers (host:port, one per line):"),
+		backendsEntry,
+		widget.NewLabel("Algorithm:"),
+		algoSelect,
+		widget.NewLabel("Dial retries:"),
+		retryEntry,
+		widget.NewLabel("Drain timeout (seconds):"),
+		drainEntry,
+		advanced,
+		sinks,
+		container.NewHBox(startBtn, stopBtn),
+		statusLabel,
+		widget.NewLabel("Admin API Port:"),
+		adminPortEntry,
+		container.NewHBox(adminStartBtn, adminStopBtn),
+		adminStatusLabel,
+		widget.NewLabel("Active Connections:"),
+		list,
+		widget.NewLabel("Backends:"),
+		backendList,
+	)
+
+	w.SetContent(form)
+	w.Resize(fyne.NewSize(500, 700))
+
+	// A terminal kill (SIGINT/SIGTERM) should drain in-flight connections
+	// the same way the Stop button does before the app actually exits.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		statusLabel.SetText("Status: Draining...")
+		manager.StopListener(drainProgress)
+		_ = admin.Stop()
+		myApp.Quit()
+	}()
+
+	// We'll set up a ticker to refresh the lists
+	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		for range ticker.C {
+			if w.Content() == nil {
+				ticker.Stop()
+				return
+			}
+			// Refresh the lists from this ticker goroutine
+			list.Refresh()
+			backendList.Refresh()
+		}
+	}()
+
+	w.ShowAndRun()
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConnectionInfo holds metadata for a single active connection
+type ConnectionInfo struct {
+	ID          string
+	Source      string
+	Destination string
+	StartTime   time.Time
+}
+
+// proxiedConn is the pair of sockets backing one handleClient connection,
+// kept around so a timed-out drain can force-close it.
+type proxiedConn struct {
+	clientConn  net.Conn
+	backendConn net.Conn
+}
+
+// LBManager manages the load balancer state
+type LBManager struct {
+	mu          sync.Mutex // protects activeConns, backends and conns
+	activeConns []ConnectionInfo
+	backends    []*Backend
+	balancer    Balancer
+	conns       map[string]*proxiedConn // connID -> sockets, for forced drain close
+	metrics     *Metrics
+	sink        Sink // optional; nil means no telemetry beyond in-memory state
+
+	healthCfg    HealthCheckConfig
+	retryCount   int
+	drainTimeout time.Duration
+	healthStop   map[*Backend]chan struct{}
+
+	wg             sync.WaitGroup     // tracks in-flight handleClient goroutines
+	listenerCancel context.CancelFunc // stops the Accept loop only
+	forceCtx       context.Context    // canceled once the drain timeout elapses
+	forceCancel    context.CancelFunc
+	running        bool
+}
+
+// NewLBManager returns an LBManager ready to have StartListener called on it.
+func NewLBManager() *LBManager {
+	return &LBManager{
+		activeConns: make([]ConnectionInfo, 0),
+		metrics:     NewMetrics(),
+	}
+}
+
+// AddConnection adds a connection record to the active list
+func (m *LBManager) AddConnection(ci ConnectionInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.activeConns = append(m.activeConns, ci)
+}
+
+// RemoveConnectionByID removes a connection by matching ID
+func (m *LBManager) RemoveConnectionByID(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.activeConns {
+		if c.ID == id {
+			// remove it
+			m.activeConns = append(m.activeConns[:i], m.activeConns[i+1:]...)
+			break
+		}
+	}
+}
+
+// ListConnections returns a snapshot of active connections
+func (m *LBManager) ListConnections() []ConnectionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// return a copy
+	copied := make([]ConnectionInfo, len(m.activeConns))
+	copy(copied, m.activeConns)
+	return copied
+}
+
+// ListBackends returns a snapshot of the configured backends for display.
+func (m *LBManager) ListBackends() []*Backend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := make([]*Backend, len(m.backends))
+	copy(copied, m.backends)
+	return copied
+}
+
+// SetSink installs the telemetry sink used for connection lifecycle events.
+// Pass nil to disable telemetry.
+func (m *LBManager) SetSink(sink Sink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sink = sink
+}
+
+// AddBackend parses spec ("host:port" or "host:port,weight") and adds it to
+// the running pool, starting its health checker with the currently
+// configured HealthCheckConfig.
+func (m *LBManager) AddBackend(spec string) error {
+	b, err := parseBackendSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	m.mu.Lock()
+	m.backends = append(m.backends, b)
+	if m.healthStop == nil {
+		m.healthStop = make(map[*Backend]chan struct{})
+	}
+	m.healthStop[b] = stop
+	m.mu.Unlock()
+
+	go runHealthChecker(b, m.healthCfg, stop)
+	return nil
+}
+
+// RemoveBackend stops health checking and removes the backend matching
+// address from the pool. In-flight connections to it are left to finish.
+func (m *LBManager) RemoveBackend(address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, b := range m.backends {
+		if b.Address == address {
+			if stop, ok := m.healthStop[b]; ok {
+				close(stop)
+				delete(m.healthStop, b)
+			}
+			m.backends = append(m.backends[:i], m.backends[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no backend with address %q", address)
+}
+
+// currentSink returns the installed telemetry sink, if any.
+func (m *LBManager) currentSink() Sink {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sink
+}
+
+// healthyBackends returns the subset of backends currently eligible for
+// selection, excluding those already in exclude.
+func (m *LBManager) healthyBackends(exclude map[*Backend]bool) []*Backend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var healthy []*Backend
+	for _, b := range m.backends {
+		if exclude[b] {
+			continue
+		}
+		if b.Healthy() {
+			healthy = append(healthy, b)
+		}
+	}
+	return healthy
+}
+
+// pickBackend asks the configured Balancer for the next backend to use,
+// excluding any already attempted for this connection.
+func (m *LBManager) pickBackend(clientAddr string, exclude map[*Backend]bool) (*Backend, error) {
+	candidates := m.healthyBackends(exclude)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no healthy backends available")
+	}
+	return m.balancer.Pick(candidates, clientAddr)
+}
+
+// parseBackendSpec parses a "host:port" or "host:port,weight" line into a Backend.
+func parseBackendSpec(spec string) (*Backend, error) {
+	addr, weight := spec, 1
+	if idx := strings.Index(spec, ","); idx != -1 {
+		addr = spec[:idx]
+		w, err := strconv.Atoi(strings.TrimSpace(spec[idx+1:]))
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid weight in backend spec %q", spec)
+		}
+		weight = w
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend format: %s", addr)
+	}
+	return NewBackend(net.JoinHostPort(host, port), weight), nil
+}
+
+// StartOptions bundles everything needed to start the listener. It has
+// grown too large for a positional argument list as features accreted.
+type StartOptions struct {
+	ListenPort   int
+	BackendSpecs []string
+	Balancer     string
+	HealthCheck  HealthCheckConfig
+	RetryCount   int
+	DrainTimeout time.Duration
+	TCPTuning    TCPTuningConfig
+
+	// OnAcceptError is called with non-temporary Accept errors so the
+	// caller can surface them (e.g. to a UI status label) instead of
+	// them being silently logged and retried.
+	OnAcceptError func(error)
+}
+
+const (
+	minAcceptBackoff = time.Millisecond
+	maxAcceptBackoff = time.Second
+)
+
+// StartListener starts the load balancer in a background goroutine
+func (m *LBManager) StartListener(opts StartOptions) error {
+	if m.running {
+		return fmt.Errorf("already running")
+	}
+
+	backends := make([]*Backend, 0, len(opts.BackendSpecs))
+	for _, spec := range opts.BackendSpecs {
+		b, err := parseBackendSpec(spec)
+		if err != nil {
+			return err
+		}
+		backends = append(backends, b)
+	}
+
+	m.mu.Lock()
+	m.backends = backends
+	m.conns = make(map[string]*proxiedConn)
+	m.mu.Unlock()
+
+	m.balancer = newBalancer(opts.Balancer)
+	m.healthCfg = opts.HealthCheck
+	m.retryCount = opts.RetryCount
+	m.drainTimeout = opts.DrainTimeout
+	m.running = true
+
+	acceptCtx, acceptCancel := context.WithCancel(context.Background())
+	m.listenerCancel = acceptCancel
+	m.forceCtx, m.forceCancel = context.WithCancel(context.Background())
+
+	m.healthStop = make(map[*Backend]chan struct{}, len(backends))
+	for _, b := range backends {
+		stop := make(chan struct{})
+		m.healthStop[b] = stop
+		go runHealthChecker(b, opts.HealthCheck, stop)
+	}
+
+	go func() {
+		defer func() {
+			m.running = false
+		}()
+		listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: opts.ListenPort})
+		if err != nil {
+			log.Printf("Error listening on port %d: %v\n", opts.ListenPort, err)
+			return
+		}
+		defer listener.Close()
+
+		log.Printf("Load balancer listening on port %d\n", opts.ListenPort)
+
+		backoff := minAcceptBackoff
+		for {
+			select {
+			case <-acceptCtx.Done():
+				// stop signaled: stop accepting new connections
+				log.Println("Stopping load balancer...")
+				return
+			default:
+			}
+
+			// Accept new connections
+			listener.SetDeadline(time.Now().Add(200 * time.Millisecond)) // short timeout so we can check acceptCtx
+			conn, err := listener.AcceptTCP()
+			if err != nil {
+				netErr, ok := err.(net.Error)
+				if ok && netErr.Timeout() {
+					// normal wake-up to recheck acceptCtx, not a real error
+					continue
+				}
+				if ok && netErr.Temporary() {
+					sleep := backoff + time.Duration(rand.Int63n(int64(backoff)+1))
+					log.Printf("Temporary accept error: %v (backing off %v)\n", err, sleep)
+					time.Sleep(sleep)
+					if backoff *= 2; backoff > maxAcceptBackoff {
+						backoff = maxAcceptBackoff
+					}
+					continue
+				}
+				log.Printf("Accept error: %v\n", err)
+				if opts.OnAcceptError != nil {
+					opts.OnAcceptError(err)
+				}
+				continue
+			}
+			backoff = minAcceptBackoff
+
+			tuneConn(conn, opts.TCPTuning)
+
+			// We got a new client - handle in a goroutine
+			m.wg.Add(1)
+			go m.handleClient(m.forceCtx, conn)
+		}
+	}()
+	return nil
+}
+
+// StopListener stops accepting new connections, then waits up to
+// m.drainTimeout for in-flight connections to finish on their own
+// (reporting progress via onProgress), before force-closing whatever is
+// still open.
+func (m *LBManager) StopListener(onProgress func(remaining int)) {
+	if m.listenerCancel != nil {
+		m.listenerCancel() // stop accepting new connections
+	}
+	m.mu.Lock()
+	for _, stop := range m.healthStop {
+		close(stop)
+	}
+	m.healthStop = nil
+	m.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(drained)
+	}()
+
+	deadline := time.Now().Add(m.drainTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+drainLoop:
+	for {
+		remaining := len(m.ListConnections())
+		if onProgress != nil {
+			onProgress(remaining)
+		}
+		if remaining == 0 {
+			select {
+			case <-drained:
+			case <-time.After(50 * time.Millisecond):
+			}
+			break drainLoop
+		}
+		select {
+		case <-drained:
+			break drainLoop
+		case <-ticker.C:
+			if time.Now().After(deadline) {
+				break drainLoop
+			}
+		}
+	}
+
+	// Anything still open at this point gets force-closed.
+	if m.forceCancel != nil {
+		m.forceCancel()
+	}
+	m.mu.Lock()
+	for id, pc := range m.conns {
+		pc.clientConn.Close()
+		pc.backendConn.Close()
+		delete(m.conns, id)
+	}
+	m.mu.Unlock()
+
+	m.running = false
+	log.Println("StopListener called")
+}
+
+// handleClient proxies traffic to a backend, retrying against the next
+// healthy backend up to m.retryCount times if dialing fails. ctx is the
+// drain's force-close signal: it is only canceled once the drain timeout
+// elapses, so a connection in progress is left to finish naturally.
+func (m *LBManager) handleClient(ctx context.Context, clientConn net.Conn) {
+	defer m.wg.Done()
+	defer clientConn.Close()
+
+	m.metrics.IncConnections()
+
+	srcAddr := clientConn.RemoteAddr().String()
+	tried := make(map[*Backend]bool)
+
+	var backend *Backend
+	var backendConn net.Conn
+	for attempt := 0; attempt <= m.retryCount; attempt++ {
+		b, err := m.pickBackend(srcAddr, tried)
+		if err != nil {
+			log.Printf("Error picking backend: %v\n", err)
+			return
+		}
+		tried[b] = true
+
+		conn, err := net.Dial("tcp", b.Address)
+		if err != nil {
+			log.Printf("Error connecting to backend %s => %v (attempt %d/%d)\n", b.Address, err, attempt+1, m.retryCount+1)
+			m.metrics.RecordDialError(b.Address)
+			continue
+		}
+		backend = b
+		backendConn = conn
+		break
+	}
+	if backendConn == nil {
+		return
+	}
+	defer backendConn.Close()
+
+	backend.acquire()
+	defer backend.release()
+
+	connStart := time.Now()
+	defer func() { m.metrics.ObserveDuration(time.Since(connStart)) }()
+
+	// create connection record
+	connID := fmt.Sprintf("%d", rand.Uint64())
+	ci := ConnectionInfo{
+		ID:          connID,
+		Source:      srcAddr,
+		Destination: backend.Address,
+		StartTime:   time.Now(),
+	}
+	m.AddConnection(ci)
+	defer m.RemoveConnectionByID(connID)
+
+	sink := m.currentSink()
+	if sink != nil {
+		sink.OnConnectionStart(ci)
+	}
+
+	pc := &proxiedConn{clientConn: clientConn, backendConn: backendConn}
+	m.mu.Lock()
+	m.conns[connID] = pc
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.conns, connID)
+		m.mu.Unlock()
+	}()
+
+	// copy data both ways, capturing the real byte totals and any copy
+	// error instead of discarding them
+	done := make(chan struct{}, 2)
+	var bytesC2B, bytesB2C int64
+	var errC2B, errB2C error
+
+	go func() {
+		defer func() { done <- struct{}{} }()
+		n, err := io.Copy(backendConn, clientConn)
+		bytesC2B, errC2B = n, err
+		m.metrics.AddBytes("client_to_backend", n)
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		n, err := io.Copy(clientConn, backendConn)
+		bytesB2C, errB2C = n, err
+		m.metrics.AddBytes("backend_to_client", n)
+	}()
+
+	// Wait for both directions to finish, so the end event below carries
+	// accurate totals. A drain force-close (ctx canceled) unblocks the
+	// in-flight io.Copy calls by closing the sockets, rather than abandoning
+	// the goroutines.
+	forceClosed := false
+	for remaining := 2; remaining > 0; {
+		if forceClosed {
+			<-done
+			remaining--
+			continue
+		}
+		select {
+		case <-done:
+			remaining--
+		case <-ctx.Done():
+			clientConn.Close()
+			backendConn.Close()
+			forceClosed = true
+		}
+	}
+
+	copyErr := errC2B
+	if copyErr == nil {
+		copyErr = errB2C
+	}
+	if sink != nil {
+		sink.OnConnectionEnd(ci, bytesC2B, bytesB2C, time.Since(connStart), copyErr)
+	}
+}
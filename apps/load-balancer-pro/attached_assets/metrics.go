@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connectionDurationBuckets are the upper bounds (seconds) for the
+// connection_duration_seconds histogram.
+var connectionDurationBuckets = []float64{0.1, 0.5, 1, 5, 30, 60, 300}
+
+// Metrics accumulates the counters and histogram backing the Prometheus
+// /metrics endpoint. It lives for the process lifetime, independent of
+// listener start/stop cycles.
+type Metrics struct {
+	mu               sync.Mutex
+	connectionsTotal int64
+	dialErrors       map[string]int64 // backend address -> count
+	bytesForwarded   map[string]int64 // direction -> bytes
+	bucketCounts     []int64          // parallel to connectionDurationBuckets, plus one +Inf bucket
+	durationSum      float64
+	durationCount    int64
+}
+
+// NewMetrics returns an empty Metrics registry.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		dialErrors:     make(map[string]int64),
+		bytesForwarded: make(map[string]int64),
+		bucketCounts:   make([]int64, len(connectionDurationBuckets)+1),
+	}
+}
+
+// IncConnections records one accepted connection.
+func (m *Metrics) IncConnections() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connectionsTotal++
+}
+
+// RecordDialError records one failed dial to backend.
+func (m *Metrics) RecordDialError(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dialErrors[backend]++
+}
+
+// AddBytes records n bytes forwarded in the given direction
+// ("client_to_backend" or "backend_to_client").
+func (m *Metrics) AddBytes(direction string, n int64) {
+	if n <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesForwarded[direction] += n
+}
+
+// ObserveDuration folds one completed connection's lifetime into the
+// connection_duration_seconds histogram.
+func (m *Metrics) ObserveDuration(d time.Duration) {
+	secs := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durationSum += secs
+	m.durationCount++
+	for i, bound := range connectionDurationBuckets {
+		if secs <= bound {
+			m.bucketCounts[i]++
+		}
+	}
+	m.bucketCounts[len(m.bucketCounts)-1]++ // +Inf
+}
+
+// RenderPrometheus renders the registry in Prometheus text exposition
+// format. connectionsActive is sampled by the caller since it's derived
+// from the manager's live connection list rather than tracked here.
+func (m *Metrics) RenderPrometheus(connectionsActive int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+
+	sb.WriteString("# HELP connections_total Total connections accepted by the load balancer.\n")
+	sb.WriteString("# TYPE connections_total counter\n")
+	fmt.Fprintf(&sb, "connections_total %d\n", m.connectionsTotal)
+
+	sb.WriteString("# HELP connections_active Connections currently being proxied.\n")
+	sb.WriteString("# TYPE connections_active gauge\n")
+	fmt.Fprintf(&sb, "connections_active %d\n", connectionsActive)
+
+	sb.WriteString("# HELP backend_dial_errors_total Backend dial failures.\n")
+	sb.WriteString("# TYPE backend_dial_errors_total counter\n")
+	for backend, n := range m.dialErrors {
+		fmt.Fprintf(&sb, "backend_dial_errors_total{backend=%q} %d\n", backend, n)
+	}
+
+	sb.WriteString("# HELP bytes_forwarded_total Bytes proxied per direction.\n")
+	sb.WriteString("# TYPE bytes_forwarded_total counter\n")
+	for direction, n := range m.bytesForwarded {
+		fmt.Fprintf(&sb, "bytes_forwarded_total{direction=%q} %d\n", direction, n)
+	}
+
+	sb.WriteString("# HELP connection_duration_seconds Duration of proxied connections.\n")
+	sb.WriteString("# TYPE connection_duration_seconds histogram\n")
+	for i, bound := range connectionDurationBuckets {
+		fmt.Fprintf(&sb, "connection_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), m.bucketCounts[i])
+	}
+	fmt.Fprintf(&sb, "connection_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.bucketCounts[len(m.bucketCounts)-1])
+	fmt.Fprintf(&sb, "connection_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(&sb, "connection_duration_seconds_count %d\n", m.durationCount)
+
+	return sb.String()
+}
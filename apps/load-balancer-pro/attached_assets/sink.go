@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Sink receives lifecycle events for proxied connections, for post-hoc
+// analysis of traffic the Fyne window alone can't preserve.
+type Sink interface {
+	OnConnectionStart(ci ConnectionInfo)
+	OnConnectionEnd(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error)
+}
+
+// connectionEvent is the JSON shape written by the Filesystem and HTTP sinks.
+type connectionEvent struct {
+	Event                string    `json:"event"` // "start" or "end"
+	ID                   string    `json:"id"`
+	Source               string    `json:"source"`
+	Destination          string    `json:"destination"`
+	StartTime            time.Time `json:"startTime"`
+	DurationSeconds      float64   `json:"durationSeconds,omitempty"`
+	BytesClientToBackend int64     `json:"bytesClientToBackend,omitempty"`
+	BytesBackendToClient int64     `json:"bytesBackendToClient,omitempty"`
+	Error                string    `json:"error,omitempty"`
+}
+
+func startEvent(ci ConnectionInfo) connectionEvent {
+	return connectionEvent{Event: "start", ID: ci.ID, Source: ci.Source, Destination: ci.Destination, StartTime: ci.StartTime}
+}
+
+func endEvent(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error) connectionEvent {
+	ev := connectionEvent{
+		Event:                "end",
+		ID:                   ci.ID,
+		Source:               ci.Source,
+		Destination:          ci.Destination,
+		StartTime:            ci.StartTime,
+		DurationSeconds:      duration.Seconds(),
+		BytesClientToBackend: bytesClientToBackend,
+		BytesBackendToClient: bytesBackendToClient,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
+
+// ConsoleSink writes human-readable lines to stdout or stderr.
+type ConsoleSink struct {
+	out io.Writer
+}
+
+// NewConsoleSink returns a ConsoleSink writing to out (typically os.Stdout
+// or os.Stderr, selected from the GUI dropdown).
+func NewConsoleSink(out io.Writer) *ConsoleSink {
+	return &ConsoleSink{out: out}
+}
+
+func (s *ConsoleSink) OnConnectionStart(ci ConnectionInfo) {
+	fmt.Fprintf(s.out, "[conn start] id=%s %s -> %s at=%s\n", ci.ID, ci.Source, ci.Destination, ci.StartTime.Format(time.RFC3339))
+}
+
+func (s *ConsoleSink) OnConnectionEnd(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error) {
+	fmt.Fprintf(s.out, "[conn end] id=%s %s -> %s duration=%s out=%dB in=%dB err=%v\n",
+		ci.ID, ci.Source, ci.Destination, duration, bytesClientToBackend, bytesBackendToClient, err)
+}
+
+// FilesystemSink appends newline-delimited JSON events to a rotating log
+// file managed by lumberjack.
+type FilesystemSink struct {
+	logger *lumberjack.Logger
+}
+
+// NewFilesystemSink returns a FilesystemSink writing to path, rotating per
+// the given limits (maxSizeMB triggers rotation, maxBackups/maxAgeDays
+// bound retention).
+func NewFilesystemSink(path string, maxSizeMB, maxBackups, maxAgeDays int) *FilesystemSink {
+	return &FilesystemSink{
+		logger: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSizeMB,
+			MaxBackups: maxBackups,
+			MaxAge:     maxAgeDays,
+		},
+	}
+}
+
+func (s *FilesystemSink) write(ev connectionEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("FilesystemSink: marshal failed: %v\n", err)
+		return
+	}
+	data = append(data, '\n')
+	if _, err := s.logger.Write(data); err != nil {
+		log.Printf("FilesystemSink: write failed: %v\n", err)
+	}
+}
+
+func (s *FilesystemSink) OnConnectionStart(ci ConnectionInfo) {
+	s.write(startEvent(ci))
+}
+
+func (s *FilesystemSink) OnConnectionEnd(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error) {
+	s.write(endEvent(ci, bytesClientToBackend, bytesBackendToClient, duration, err))
+}
+
+// HTTPSink POSTs each event as a JSON line to a user-supplied URL.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns an HTTPSink that POSTs events to url.
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *HTTPSink) post(ev connectionEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("HTTPSink: marshal failed: %v\n", err)
+		return
+	}
+	// Fire-and-forget so a slow or unreachable collector never stalls the proxy.
+	go func() {
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			log.Printf("HTTPSink: post to %s failed: %v\n", s.url, err)
+			return
+		}
+		_ = resp.Body.Close()
+	}()
+}
+
+func (s *HTTPSink) OnConnectionStart(ci ConnectionInfo) {
+	s.post(startEvent(ci))
+}
+
+func (s *HTTPSink) OnConnectionEnd(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error) {
+	s.post(endEvent(ci, bytesClientToBackend, bytesBackendToClient, duration, err))
+}
+
+// MultiSink fans events out to several sinks, e.g. logging to a file and
+// shipping to HTTP at the same time.
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink returns a MultiSink fanning out to sinks.
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+func (m *MultiSink) OnConnectionStart(ci ConnectionInfo) {
+	for _, s := range m.sinks {
+		s.OnConnectionStart(ci)
+	}
+}
+
+func (m *MultiSink) OnConnectionEnd(ci ConnectionInfo, bytesClientToBackend, bytesBackendToClient int64, duration time.Duration, err error) {
+	for _, s := range m.sinks {
+		s.OnConnectionEnd(ci, bytesClientToBackend, bytesBackendToClient, duration, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"net"
+	"time"
+)
+
+// TCPTuningConfig controls the socket options applied to each accepted
+// client connection.
+type TCPTuningConfig struct {
+	KeepAlive       bool
+	KeepAlivePeriod time.Duration
+	ReadBufferSize  int // bytes; 0 leaves the OS default
+	WriteBufferSize int // bytes; 0 leaves the OS default
+	NoDelay         bool
+}
+
+// DefaultTCPTuningConfig returns sane defaults for interactive use.
+func DefaultTCPTuningConfig() TCPTuningConfig {
+	return TCPTuningConfig{
+		KeepAlive:       true,
+		KeepAlivePeriod: 30 * time.Second,
+		ReadBufferSize:  0,
+		WriteBufferSize: 0,
+		NoDelay:         true,
+	}
+}
+
+// tuneConn applies cfg to conn, logging but not failing on individual
+// setsockopt errors since they are rarely fatal to proxying.
+func tuneConn(conn *net.TCPConn, cfg TCPTuningConfig) {
+	if err := conn.SetKeepAlive(cfg.KeepAlive); err != nil {
+		log.Printf("SetKeepAlive failed for %s: %v\n", conn.RemoteAddr(), err)
+	}
+	if cfg.KeepAlive {
+		if err := conn.SetKeepAlivePeriod(cfg.KeepAlivePeriod); err != nil {
+			log.Printf("SetKeepAlivePeriod failed for %s: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+	if cfg.ReadBufferSize > 0 {
+		if err := conn.SetReadBuffer(cfg.ReadBufferSize); err != nil {
+			log.Printf("SetReadBuffer failed for %s: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+	if cfg.WriteBufferSize > 0 {
+		if err := conn.SetWriteBuffer(cfg.WriteBufferSize); err != nil {
+			log.Printf("SetWriteBuffer failed for %s: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+	if err := conn.SetNoDelay(cfg.NoDelay); err != nil {
+		log.Printf("SetNoDelay failed for %s: %v\n", conn.RemoteAddr(), err)
+	}
+}